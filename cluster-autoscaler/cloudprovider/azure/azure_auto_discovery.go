@@ -0,0 +1,249 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+// labelSelectorOp is the comparison a labelSelectorPredicate performs
+// against a VMSS tag value.
+type labelSelectorOp int
+
+const (
+	opExists labelSelectorOp = iota
+	opNotEquals
+	opRegex
+)
+
+// labelSelectorPredicate is a single `key`, `key!=value`, or `key~=<regex>`
+// clause from a label: auto-discovery spec. Plain `key=value` equality
+// clauses are handled separately via labelAutoDiscoveryConfig.Selector,
+// since that's the overwhelmingly common case.
+type labelSelectorPredicate struct {
+	Key   string
+	Op    labelSelectorOp
+	Value string
+	regex *regexp.Regexp
+}
+
+func (p labelSelectorPredicate) matches(tags map[string]*string) bool {
+	got, ok := tags[p.Key]
+	switch p.Op {
+	case opExists:
+		return ok && got != nil
+	case opNotEquals:
+		return !ok || got == nil || *got != p.Value
+	case opRegex:
+		return ok && got != nil && p.regex.MatchString(*got)
+	default:
+		return false
+	}
+}
+
+// resourceGroupSelector is a single `rg:<name>` or `rg:~<regex>` spec
+// restricting discovery to matching resource groups.
+type resourceGroupSelector struct {
+	Name  string
+	Regex *regexp.Regexp
+}
+
+// labelAutoDiscoveryConfig is the parsed representation of one
+// --node-group-auto-discovery flag value.
+type labelAutoDiscoveryConfig struct {
+	// Selector is a map of tags that must equal the given value, the
+	// original label:key=value equality semantics.
+	Selector map[string]string
+
+	// Predicates holds the richer selectors this config also supports:
+	// tag presence (label:key), inequality (label:key!=value), and regex
+	// match on the tag value (label:key~=<regex>). A VMSS must satisfy
+	// every predicate here, as well as every entry in Selector, to match.
+	Predicates []labelSelectorPredicate
+
+	// ResourceGroups scopes discovery to the given resource groups. Empty
+	// means discover within the manager's configured resource group, as
+	// before this field existed. Besides the standalone rg:<name> and
+	// rg:~<regex> specs, a resource group can also be named as an rg= or
+	// rg~= clause within a label: spec (e.g. label:foo=bar,rg=my-rg), so
+	// the restriction applies to the same VMSS the label clauses select
+	// instead of unioning with every other spec passed alongside it.
+	ResourceGroups []resourceGroupSelector
+}
+
+// parseLabelAutoDiscoverySpecs returns any provided NodeGroupAutoDiscoverySpecs
+// parsed into configuration appropriate for k8s tag-based node group
+// auto-discovery.
+func parseLabelAutoDiscoverySpecs(o cloudprovider.NodeGroupDiscoveryOptions) ([]labelAutoDiscoveryConfig, error) {
+	cfgs := make([]labelAutoDiscoveryConfig, len(o.NodeGroupAutoDiscoverySpecs))
+	var err error
+	for i, spec := range o.NodeGroupAutoDiscoverySpecs {
+		cfgs[i], err = parseLabelAutoDiscoverySpec(spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cfgs, nil
+}
+
+func parseLabelAutoDiscoverySpec(spec string) (labelAutoDiscoveryConfig, error) {
+	cfg := labelAutoDiscoveryConfig{
+		Selector: make(map[string]string),
+	}
+
+	tokens := strings.SplitN(spec, ":", 2)
+	if len(tokens) != 2 {
+		return cfg, fmt.Errorf("spec \"%s\" should be discoverer:key=value,key=value", spec)
+	}
+	discoverer, body := tokens[0], tokens[1]
+
+	switch discoverer {
+	case "label":
+		for _, clause := range strings.Split(body, ",") {
+			if err := addLabelClause(&cfg, clause); err != nil {
+				return cfg, err
+			}
+		}
+	case "rg":
+		rg, err := parseResourceGroupClause(body)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.ResourceGroups = append(cfg.ResourceGroups, rg)
+	default:
+		return cfg, fmt.Errorf("unsupported discoverer specified: %s", discoverer)
+	}
+
+	return cfg, nil
+}
+
+func addLabelClause(cfg *labelAutoDiscoveryConfig, clause string) error {
+	switch {
+	case strings.HasPrefix(clause, "rg~="):
+		pattern := strings.TrimPrefix(clause, "rg~=")
+		if pattern == "" {
+			return fmt.Errorf("empty resource group regex in tag specification: %s", clause)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid resource group regex %q in tag specification: %s: %v", pattern, clause, err)
+		}
+		cfg.ResourceGroups = append(cfg.ResourceGroups, resourceGroupSelector{Regex: re})
+
+	case strings.HasPrefix(clause, "rg="):
+		name := strings.TrimPrefix(clause, "rg=")
+		if name == "" {
+			return fmt.Errorf("empty resource group name in tag specification: %s", clause)
+		}
+		cfg.ResourceGroups = append(cfg.ResourceGroups, resourceGroupSelector{Name: name})
+
+	case strings.Contains(clause, "!="):
+		k, v, err := splitClause(clause, "!=")
+		if err != nil {
+			return err
+		}
+		cfg.Predicates = append(cfg.Predicates, labelSelectorPredicate{Key: k, Op: opNotEquals, Value: v})
+
+	case strings.Contains(clause, "~="):
+		k, v, err := splitClause(clause, "~=")
+		if err != nil {
+			return err
+		}
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return fmt.Errorf("invalid regex %q in tag specification: %s: %v", v, clause, err)
+		}
+		cfg.Predicates = append(cfg.Predicates, labelSelectorPredicate{Key: k, Op: opRegex, Value: v, regex: re})
+
+	case strings.Contains(clause, "="):
+		k, v, err := splitClause(clause, "=")
+		if err != nil {
+			return err
+		}
+		cfg.Selector[k] = v
+
+	default:
+		if clause == "" {
+			return fmt.Errorf("empty key in tag specification")
+		}
+		cfg.Predicates = append(cfg.Predicates, labelSelectorPredicate{Key: clause, Op: opExists})
+	}
+
+	return nil
+}
+
+func splitClause(clause, sep string) (key, value string, err error) {
+	parts := strings.SplitN(clause, sep, 2)
+	key, value = parts[0], parts[1]
+	if key == "" || value == "" {
+		return "", "", fmt.Errorf("empty key/value in tag specification: %s", clause)
+	}
+	return key, value, nil
+}
+
+func parseResourceGroupClause(clause string) (resourceGroupSelector, error) {
+	if clause == "" {
+		return resourceGroupSelector{}, fmt.Errorf("empty resource group selector")
+	}
+
+	if strings.HasPrefix(clause, "~") {
+		pattern := strings.TrimPrefix(clause, "~")
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return resourceGroupSelector{}, fmt.Errorf("invalid resource group regex %q: %v", pattern, err)
+		}
+		return resourceGroupSelector{Regex: re}, nil
+	}
+
+	return resourceGroupSelector{Name: clause}, nil
+}
+
+// matchDiscoverySpecs reports whether tags satisfies at least one of specs.
+func matchDiscoverySpecs(tags map[string]*string, specs []labelAutoDiscoveryConfig) bool {
+	for _, spec := range specs {
+		if matchesConfig(tags, spec) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesConfig(tags map[string]*string, cfg labelAutoDiscoveryConfig) bool {
+	if !matchesSelector(tags, cfg.Selector) {
+		return false
+	}
+	for _, pred := range cfg.Predicates {
+		if !pred.matches(tags) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesSelector(tags map[string]*string, selector map[string]string) bool {
+	for k, v := range selector {
+		got, ok := tags[k]
+		if !ok || got == nil || *got != v {
+			return false
+		}
+	}
+	return true
+}
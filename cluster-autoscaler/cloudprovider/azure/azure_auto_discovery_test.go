@@ -0,0 +1,235 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/legacy-cloud-providers/azure/clients/vmssclient/mockvmssclient"
+)
+
+func TestParseLabelAutoDiscoverySpecsExtendedSelectors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		spec        string
+		expectedErr bool
+		check       func(t *testing.T, cfg labelAutoDiscoveryConfig)
+	}{
+		{
+			name: "KeyExists",
+			spec: "label:cluster-autoscaler-enabled",
+			check: func(t *testing.T, cfg labelAutoDiscoveryConfig) {
+				assert.Len(t, cfg.Predicates, 1)
+				assert.Equal(t, opExists, cfg.Predicates[0].Op)
+				assert.Equal(t, "cluster-autoscaler-enabled", cfg.Predicates[0].Key)
+			},
+		},
+		{
+			name: "NotEquals",
+			spec: "label:env!=prod",
+			check: func(t *testing.T, cfg labelAutoDiscoveryConfig) {
+				assert.Len(t, cfg.Predicates, 1)
+				assert.Equal(t, opNotEquals, cfg.Predicates[0].Op)
+				assert.Equal(t, "env", cfg.Predicates[0].Key)
+				assert.Equal(t, "prod", cfg.Predicates[0].Value)
+			},
+		},
+		{
+			name: "Regex",
+			spec: "label:pool~=^spot-.*$",
+			check: func(t *testing.T, cfg labelAutoDiscoveryConfig) {
+				assert.Len(t, cfg.Predicates, 1)
+				assert.Equal(t, opRegex, cfg.Predicates[0].Op)
+				assert.True(t, cfg.Predicates[0].matches(map[string]*string{"pool": strPtr("spot-a")}))
+				assert.False(t, cfg.Predicates[0].matches(map[string]*string{"pool": strPtr("od-a")}))
+			},
+		},
+		{
+			name:        "InvalidRegex",
+			spec:        "label:pool~=(",
+			expectedErr: true,
+		},
+		{
+			name: "ResourceGroupExact",
+			spec: "rg:my-rg",
+			check: func(t *testing.T, cfg labelAutoDiscoveryConfig) {
+				assert.Len(t, cfg.ResourceGroups, 1)
+				assert.Equal(t, "my-rg", cfg.ResourceGroups[0].Name)
+				assert.Nil(t, cfg.ResourceGroups[0].Regex)
+			},
+		},
+		{
+			name: "ResourceGroupRegex",
+			spec: "rg:~^spoke-.*$",
+			check: func(t *testing.T, cfg labelAutoDiscoveryConfig) {
+				assert.Len(t, cfg.ResourceGroups, 1)
+				assert.NotNil(t, cfg.ResourceGroups[0].Regex)
+				assert.True(t, cfg.ResourceGroups[0].Regex.MatchString("spoke-1"))
+			},
+		},
+		{
+			name:        "EmptyResourceGroup",
+			spec:        "rg:",
+			expectedErr: true,
+		},
+		{
+			name: "CombinedLabelAndResourceGroup",
+			spec: "label:env=staging,rg=my-rg",
+			check: func(t *testing.T, cfg labelAutoDiscoveryConfig) {
+				assert.Equal(t, map[string]string{"env": "staging"}, cfg.Selector)
+				assert.Len(t, cfg.ResourceGroups, 1)
+				assert.Equal(t, "my-rg", cfg.ResourceGroups[0].Name)
+			},
+		},
+		{
+			name: "CombinedLabelAndResourceGroupRegex",
+			spec: "label:env=staging,rg~=^spoke-.*$",
+			check: func(t *testing.T, cfg labelAutoDiscoveryConfig) {
+				assert.Equal(t, map[string]string{"env": "staging"}, cfg.Selector)
+				assert.Len(t, cfg.ResourceGroups, 1)
+				assert.NotNil(t, cfg.ResourceGroups[0].Regex)
+				assert.True(t, cfg.ResourceGroups[0].Regex.MatchString("spoke-1"))
+			},
+		},
+		{
+			name:        "EmptyCombinedResourceGroup",
+			spec:        "label:env=staging,rg=",
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := parseLabelAutoDiscoverySpec(tc.spec)
+			if tc.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			tc.check(t, cfg)
+		})
+	}
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestListScalesetsExtendedSelectors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	manager := newTestAzureManager(t)
+
+	matchingTags := map[string]*string{
+		"cluster-autoscaler-enabled": strPtr("true"),
+		"env":                        strPtr("staging"),
+		"min":                        strPtr("1"),
+		"max":                        strPtr("5"),
+	}
+	nonMatchingTags := map[string]*string{
+		"cluster-autoscaler-enabled": strPtr("true"),
+		"env":                        strPtr("prod"),
+		"min":                        strPtr("1"),
+		"max":                        strPtr("5"),
+	}
+
+	vmssList := []compute.VirtualMachineScaleSet{
+		fakeVMSSWithTags("matching-vmss", matchingTags),
+		fakeVMSSWithTags("prod-vmss", nonMatchingTags),
+	}
+
+	mockVMSSClient := mockvmssclient.NewMockInterface(ctrl)
+	mockVMSSClient.EXPECT().List(gomock.Any(), manager.config.ResourceGroup).Return(vmssList, nil).AnyTimes()
+	manager.azClient.virtualMachineScaleSetsClient = mockVMSSClient
+
+	ngdo := cloudprovider.NodeGroupDiscoveryOptions{
+		NodeGroupAutoDiscoverySpecs: []string{"label:cluster-autoscaler-enabled,env!=prod"},
+	}
+	specs, err := parseLabelAutoDiscoverySpecs(ngdo)
+	assert.NoError(t, err)
+
+	asgs, err := manager.listScaleSets(specs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(asgs))
+	assert.Equal(t, "matching-vmss", asgs[0].Id())
+}
+
+func TestResourceGroupsForSpecsDefaultsToManagerGroup(t *testing.T) {
+	manager := newTestAzureManager(t)
+
+	groups, err := manager.resourceGroupsForSpecs([]labelAutoDiscoveryConfig{{Selector: map[string]string{"a": "b"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{manager.config.ResourceGroup}, groups)
+}
+
+func TestResourceGroupsForSpecsHonorsRGSelector(t *testing.T) {
+	manager := newTestAzureManager(t)
+
+	specs := []labelAutoDiscoveryConfig{
+		{ResourceGroups: []resourceGroupSelector{{Name: "spoke-1"}, {Name: "spoke-2"}}},
+	}
+	groups, err := manager.resourceGroupsForSpecs(specs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"spoke-1", "spoke-2"}, groups)
+}
+
+func TestResourceGroupsForSpecsHonorsMatchingRGRegex(t *testing.T) {
+	manager := newTestAzureManager(t)
+
+	re := regexp.MustCompile("^" + manager.config.ResourceGroup + "$")
+	specs := []labelAutoDiscoveryConfig{
+		{ResourceGroups: []resourceGroupSelector{{Regex: re}}},
+	}
+	groups, err := manager.resourceGroupsForSpecs(specs)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{manager.config.ResourceGroup}, groups)
+}
+
+func TestResourceGroupsForSpecsErrorsOnNonMatchingRGRegex(t *testing.T) {
+	manager := newTestAzureManager(t)
+
+	re := regexp.MustCompile("^spoke-.*$")
+	specs := []labelAutoDiscoveryConfig{
+		{ResourceGroups: []resourceGroupSelector{{Regex: re}}},
+	}
+	_, err := manager.resourceGroupsForSpecs(specs)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match the configured resource group")
+}
+
+// TestMatchesConfigCombinedResourceGroupNarrowsRatherThanUnions guards
+// against a spec carrying only an rg selector and no label predicates
+// trivially matching every tagged VMSS: with the combined label:...,rg=...
+// syntax, the rg clause lives on the same config as the label predicates,
+// so it can only narrow that config's matches, never wildcard-match on its
+// own the way a bare rg:<name> spec unioned with an unrelated label: spec
+// effectively would.
+func TestMatchesConfigCombinedResourceGroupNarrowsRatherThanUnions(t *testing.T) {
+	cfg, err := parseLabelAutoDiscoverySpec("label:foo=bar,rg=my-rg")
+	assert.NoError(t, err)
+
+	matchingTags := map[string]*string{"foo": strPtr("bar"), "min": strPtr("1"), "max": strPtr("5")}
+	nonMatchingTags := map[string]*string{"min": strPtr("1"), "max": strPtr("5")}
+
+	assert.True(t, matchesConfig(matchingTags, cfg))
+	assert.False(t, matchesConfig(nonMatchingTags, cfg))
+}
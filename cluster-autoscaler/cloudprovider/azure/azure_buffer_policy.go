@@ -0,0 +1,92 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	vmssBufferTag    = "buffer"
+	vmssBufferKeyTag = "bufferKey"
+)
+
+// bufferPolicy is a VMSS's capacity-buffer scaling policy, parsed from its
+// buffer/bufferKey auto-discovery tags: keep `amount` (or `percent` of
+// current utilization) worth of headroom on top of the utilization along
+// the `key` resource dimension, ported from the "count + capacity + buffer"
+// idea in Agones' fleet autoscaler ListPolicy. Only an empty key, "pods" and
+// "nodes" have a utilization source (the scale set's instance count); for
+// any other key, such as cpu or memory, AzureManager.currentUtilization
+// returns an error and the policy is not applied.
+type bufferPolicy struct {
+	set     bool
+	amount  int
+	percent float64
+	key     string
+}
+
+// parseBufferPolicy reads the buffer/bufferKey tags off a VMSS. It returns
+// the zero bufferPolicy (set == false) when the buffer tag is absent.
+func parseBufferPolicy(tags map[string]*string) (bufferPolicy, error) {
+	bufferTag, ok := tags[vmssBufferTag]
+	if !ok || bufferTag == nil {
+		return bufferPolicy{}, nil
+	}
+
+	key := ""
+	if k, ok := tags[vmssBufferKeyTag]; ok && k != nil {
+		key = *k
+	}
+
+	raw := strings.TrimSpace(*bufferTag)
+	if strings.HasSuffix(raw, "%") {
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(raw, "%"), 64)
+		if err != nil {
+			return bufferPolicy{}, fmt.Errorf("invalid buffer percentage %q: %v", raw, err)
+		}
+		return bufferPolicy{set: true, percent: pct / 100, key: key}, nil
+	}
+
+	amount, err := strconv.Atoi(raw)
+	if err != nil {
+		return bufferPolicy{}, fmt.Errorf("invalid buffer amount %q: %v", raw, err)
+	}
+	return bufferPolicy{set: true, amount: amount, key: key}, nil
+}
+
+// effectiveSize computes max(minSize, ceil(utilization + buffer)) clamped to
+// maxSize, where buffer is either the absolute amount or percent of
+// utilization the policy specifies.
+func (p bufferPolicy) effectiveSize(utilization float64, minSize, maxSize int) int {
+	buffer := float64(p.amount)
+	if p.percent != 0 {
+		buffer = utilization * p.percent
+	}
+
+	desired := int(math.Ceil(utilization + buffer))
+	if desired < minSize {
+		desired = minSize
+	}
+	if desired > maxSize {
+		desired = maxSize
+	}
+	return desired
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"sync"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+// asgCache tracks the node groups (VMSS or explicit ASGs) that the manager
+// has discovered, keyed by name so re-registration is idempotent.
+type asgCache struct {
+	mutex    sync.Mutex
+	registry map[string]cloudprovider.NodeGroup
+}
+
+func newAsgCache() *asgCache {
+	return &asgCache{
+		registry: make(map[string]cloudprovider.NodeGroup),
+	}
+}
+
+// register adds or replaces the node group with the given name.
+func (m *asgCache) register(asg cloudprovider.NodeGroup) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.registry[asg.Id()] = asg
+}
+
+// get returns all currently registered node groups.
+func (m *asgCache) get() []cloudprovider.NodeGroup {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	asgs := make([]cloudprovider.NodeGroup, 0, len(m.registry))
+	for _, asg := range m.registry {
+		asgs = append(asgs, asg)
+	}
+	return asgs
+}
@@ -0,0 +1,34 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"k8s.io/legacy-cloud-providers/azure/clients/vmssclient"
+	"k8s.io/legacy-cloud-providers/azure/clients/vmssvmclient"
+)
+
+// azClient contains all the Azure clients used by the autoscaler.
+type azClient struct {
+	virtualMachineScaleSetsClient   vmssclient.Interface
+	virtualMachineScaleSetVMsClient vmssvmclient.Interface
+}
+
+func newAzClient(cfg *Config) (*azClient, error) {
+	// Returns an azClient with no clients set; cfg is not read. Tests assign
+	// mock clients to its fields.
+	return &azClient{}, nil
+}
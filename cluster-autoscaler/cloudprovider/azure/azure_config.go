@@ -0,0 +1,76 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+)
+
+var (
+	configValidate   = validator.New()
+	configTranslator ut.Translator
+)
+
+func init() {
+	locale := en.New()
+	uni := ut.New(locale, locale)
+	configTranslator, _ = uni.GetTranslator("en")
+	_ = entranslations.RegisterDefaultTranslations(configValidate, configTranslator)
+
+	// Report violations by Go field name (e.g. "SubscriptionID") rather than
+	// the lowercase struct-tag name validator defaults to, so messages match
+	// what operators see in the Config type itself.
+	configValidate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Name
+	})
+}
+
+// Validate checks that all required fields are set and that field values
+// are within their supported ranges, collecting every violation into a
+// single error instead of failing on the first one found.
+func (c *Config) Validate() error {
+	var messages []string
+	if err := configValidate.Struct(c); err != nil {
+		validationErrors, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return fmt.Errorf("failed to validate config: %v", err)
+		}
+
+		for _, fieldErr := range validationErrors {
+			messages = append(messages, fmt.Sprintf("Config.%s", fieldErr.Translate(configTranslator)))
+		}
+	}
+
+	// interfaceRateLimit gates the per-VM network-interface lookups standard
+	// (non-VMSS) mode does; VMSS mode manages scale sets directly and never
+	// calls that client, so an override there would be a no-op at best.
+	if c.VMType == vmTypeVMSS && c.CloudProviderRateLimitConfig.InterfaceRateLimit != nil {
+		messages = append(messages, fmt.Sprintf("interfaceRateLimit is only supported for vmType %q", vmTypeStandard))
+	}
+
+	if len(messages) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
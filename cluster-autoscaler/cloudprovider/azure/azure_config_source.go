@@ -0,0 +1,194 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	cloudEnvVar               = "AZURE_CLOUD"
+	locationEnvVar            = "AZURE_LOCATION"
+	tenantIDEnvVar            = "AZURE_TENANT_ID"
+	subscriptionIDEnvVar      = "AZURE_SUBSCRIPTION_ID"
+	resourceGroupEnvVar       = "AZURE_RESOURCE_GROUP"
+	vmTypeEnvVar              = "AZURE_VM_TYPE"
+	aadClientIDEnvVar         = "AZURE_AAD_CLIENT_ID"
+	aadClientSecretEnvVar     = "AZURE_AAD_CLIENT_SECRET"
+	vmssCacheTTLEnvVar        = "AZURE_VMSS_CACHE_TTL"
+	maxDeploymentsCountEnvVar = "AZURE_MAX_DEPLOYMENTS_COUNT"
+)
+
+// parseConfig unmarshals body into a Config, auto-detecting JSON vs YAML
+// from the first non-whitespace byte so CreateAzureManager can accept
+// either shape without callers having to say which one they sent.
+func parseConfig(body []byte) (*Config, error) {
+	cfg := &Config{}
+
+	if looksLikeJSON(body) {
+		if err := json.Unmarshal(body, cfg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal config body: %v", err)
+		}
+		return cfg, nil
+	}
+
+	if err := yaml.Unmarshal(body, cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config body: %v", err)
+	}
+	return cfg, nil
+}
+
+func looksLikeJSON(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	return len(trimmed) > 0 && trimmed[0] == '{'
+}
+
+// parseConfigFile unmarshals body read from path, dispatching on the file
+// extension (.json, .yaml/.yml) so CreateAzureManagerFromFile behaves
+// predictably regardless of the content, falling back to the same
+// content-sniffing parseConfig uses for any other or missing extension.
+func parseConfigFile(path string, body []byte) (*Config, error) {
+	cfg := &Config{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		if err := json.Unmarshal(body, cfg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal config file %q: %v", path, err)
+		}
+		return cfg, nil
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(body, cfg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal config file %q: %v", path, err)
+		}
+		return cfg, nil
+	default:
+		return parseConfig(body)
+	}
+}
+
+// applyConfigEnvOverrides overlays AZURE_* environment variables onto cfg,
+// extending the rateLimit*EnvVar pattern already used for rate limits to
+// every top-level Config field. It sits between the file and any
+// programmatic Merge callers apply, per the file -> env -> override
+// precedence pipeline.
+func applyConfigEnvOverrides(cfg *Config) {
+	if v, ok := os.LookupEnv(cloudEnvVar); ok {
+		cfg.Cloud = v
+	}
+	if v, ok := os.LookupEnv(locationEnvVar); ok {
+		cfg.Location = v
+	}
+	if v, ok := os.LookupEnv(tenantIDEnvVar); ok {
+		cfg.TenantID = v
+	}
+	if v, ok := os.LookupEnv(subscriptionIDEnvVar); ok {
+		cfg.SubscriptionID = v
+	}
+	if v, ok := os.LookupEnv(resourceGroupEnvVar); ok {
+		cfg.ResourceGroup = v
+	}
+	if v, ok := os.LookupEnv(vmTypeEnvVar); ok {
+		cfg.VMType = v
+	}
+	if v, ok := os.LookupEnv(aadClientIDEnvVar); ok {
+		cfg.AADClientID = v
+	}
+	if v, ok := os.LookupEnv(aadClientSecretEnvVar); ok {
+		cfg.AADClientSecret = v
+	}
+	if v, ok := os.LookupEnv(vmssCacheTTLEnvVar); ok {
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+			cfg.VmssCacheTTL = parsed
+		}
+	}
+	if v, ok := os.LookupEnv(maxDeploymentsCountEnvVar); ok {
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+			cfg.MaxDeploymentsCount = parsed
+		}
+	}
+}
+
+// Merge overlays every non-zero-value field of other onto c and returns c,
+// so a partial YAML/JSON overlay can be layered on top of a base Config
+// without clobbering fields the overlay didn't set. The embedded
+// RateLimitConfig is the exception: it is copied as a whole, zero fields
+// included, and only when other.CloudProviderRateLimit is true.
+func (c *Config) Merge(other *Config) *Config {
+	if other == nil {
+		return c
+	}
+
+	if other.Cloud != "" {
+		c.Cloud = other.Cloud
+	}
+	if other.Location != "" {
+		c.Location = other.Location
+	}
+	if other.TenantID != "" {
+		c.TenantID = other.TenantID
+	}
+	if other.SubscriptionID != "" {
+		c.SubscriptionID = other.SubscriptionID
+	}
+	if other.ResourceGroup != "" {
+		c.ResourceGroup = other.ResourceGroup
+	}
+	if other.VMType != "" {
+		c.VMType = other.VMType
+	}
+	if other.AADClientID != "" {
+		c.AADClientID = other.AADClientID
+	}
+	if other.AADClientSecret != "" {
+		c.AADClientSecret = other.AADClientSecret
+	}
+	if other.VmssCacheTTL != 0 {
+		c.VmssCacheTTL = other.VmssCacheTTL
+	}
+	if other.MaxDeploymentsCount != 0 {
+		c.MaxDeploymentsCount = other.MaxDeploymentsCount
+	}
+
+	if other.CloudProviderRateLimit {
+		c.CloudProviderRateLimitConfig.RateLimitConfig = other.RateLimitConfig
+	}
+	if other.InterfaceRateLimit != nil {
+		c.InterfaceRateLimit = other.InterfaceRateLimit
+	}
+	if other.VirtualMachineRateLimit != nil {
+		c.VirtualMachineRateLimit = other.VirtualMachineRateLimit
+	}
+	if other.StorageAccountRateLimit != nil {
+		c.StorageAccountRateLimit = other.StorageAccountRateLimit
+	}
+	if other.DiskRateLimit != nil {
+		c.DiskRateLimit = other.DiskRateLimit
+	}
+	if other.VirtualMachineScaleSetRateLimit != nil {
+		c.VirtualMachineScaleSetRateLimit = other.VirtualMachineScaleSetRateLimit
+	}
+
+	return c
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+const validAzureCfgYAML = `
+cloud: AzurePublicCloud
+tenantId: 11111111-1111-1111-1111-111111111111
+subscriptionId: 22222222-2222-2222-2222-222222222222
+aadClientId: fakeId
+aadClientSecret: fakeId
+resourceGroup: fakeId
+location: southeastasia
+vmssCacheTTL: 60
+maxDeploymentsCount: 8
+cloudProviderRateLimit: false
+`
+
+func TestCreateAzureManagerValidConfigYAML(t *testing.T) {
+	jsonManager, err := CreateAzureManager(strings.NewReader(validAzureCfg), cloudprovider.NodeGroupDiscoveryOptions{})
+	assert.NoError(t, err)
+
+	yamlManager, err := CreateAzureManager(strings.NewReader(validAzureCfgYAML), cloudprovider.NodeGroupDiscoveryOptions{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, jsonManager.config, yamlManager.config)
+}
+
+func TestParseConfigRoundTrip(t *testing.T) {
+	jsonCfg, err := parseConfig([]byte(validAzureCfg))
+	assert.NoError(t, err)
+
+	marshaled, err := yaml.Marshal(jsonCfg)
+	assert.NoError(t, err)
+
+	yamlCfg, err := parseConfig(marshaled)
+	assert.NoError(t, err)
+
+	assert.Equal(t, jsonCfg, yamlCfg)
+}
+
+func TestApplyConfigEnvOverrides(t *testing.T) {
+	cfg, err := parseConfig([]byte(validAzureCfg))
+	assert.NoError(t, err)
+
+	os.Setenv(subscriptionIDEnvVar, "env-subscription")
+	os.Setenv(vmssCacheTTLEnvVar, "120")
+	defer os.Unsetenv(subscriptionIDEnvVar)
+	defer os.Unsetenv(vmssCacheTTLEnvVar)
+
+	applyConfigEnvOverrides(cfg)
+
+	assert.Equal(t, "env-subscription", cfg.SubscriptionID)
+	assert.Equal(t, int64(120), cfg.VmssCacheTTL)
+}
+
+func TestConfigMerge(t *testing.T) {
+	base := validTestConfig()
+	overlay := &Config{SubscriptionID: "overlay-subscription"}
+
+	merged := base.Merge(overlay)
+
+	assert.Equal(t, "overlay-subscription", merged.SubscriptionID)
+	assert.Equal(t, base.ResourceGroup, merged.ResourceGroup)
+}
+
+func TestCreateAzureManagerAppliesProgrammaticOverride(t *testing.T) {
+	override := &Config{SubscriptionID: "override-subscription"}
+
+	manager, err := CreateAzureManager(strings.NewReader(validAzureCfg), cloudprovider.NodeGroupDiscoveryOptions{}, override)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "override-subscription", manager.config.SubscriptionID)
+}
+
+func TestCreateAzureManagerFromFileRoundTrip(t *testing.T) {
+	jsonPath := filepath.Join(t.TempDir(), "cloud-config.json")
+	assert.NoError(t, ioutil.WriteFile(jsonPath, []byte(validAzureCfg), 0644))
+
+	yamlPath := filepath.Join(t.TempDir(), "cloud-config.yaml")
+	assert.NoError(t, ioutil.WriteFile(yamlPath, []byte(validAzureCfgYAML), 0644))
+
+	jsonManager, err := CreateAzureManagerFromFile(jsonPath, cloudprovider.NodeGroupDiscoveryOptions{})
+	assert.NoError(t, err)
+
+	yamlManager, err := CreateAzureManagerFromFile(yamlPath, cloudprovider.NodeGroupDiscoveryOptions{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, jsonManager.config, yamlManager.config)
+}
+
+func TestParseConfigFileDispatchesOnExtension(t *testing.T) {
+	cfg, err := parseConfigFile("cloud-config.yaml", []byte(validAzureCfgYAML))
+	assert.NoError(t, err)
+	assert.Equal(t, "AzurePublicCloud", cfg.Cloud)
+
+	cfg, err = parseConfigFile("cloud-config.json", []byte(validAzureCfg))
+	assert.NoError(t, err)
+	assert.Equal(t, "AzurePublicCloud", cfg.Cloud)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	azclients "k8s.io/legacy-cloud-providers/azure/clients"
+)
+
+func validTestConfig() *Config {
+	return &Config{
+		Cloud:               "AzurePublicCloud",
+		Location:            "southeastasia",
+		TenantID:            "11111111-1111-1111-1111-111111111111",
+		SubscriptionID:      "22222222-2222-2222-2222-222222222222",
+		ResourceGroup:       "fakeId",
+		VMType:              vmTypeVMSS,
+		AADClientID:         "fakeId",
+		AADClientSecret:     "fakeId",
+		VmssCacheTTL:        60,
+		MaxDeploymentsCount: 8,
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mutate      func(c *Config)
+		expectedErr string
+	}{
+		{
+			name:   "valid config",
+			mutate: func(c *Config) {},
+		},
+		{
+			name:        "missing required field",
+			mutate:      func(c *Config) { c.SubscriptionID = "" },
+			expectedErr: "Config.SubscriptionID is a required field",
+		},
+		{
+			name:        "invalid vmType enum",
+			mutate:      func(c *Config) { c.VMType = "vmscaleset" },
+			expectedErr: "Config.VMType",
+		},
+		{
+			name:        "out of range maxDeploymentsCount",
+			mutate:      func(c *Config) { c.MaxDeploymentsCount = 0 },
+			expectedErr: "Config.MaxDeploymentsCount",
+		},
+		{
+			name:        "negative vmssCacheTTL",
+			mutate:      func(c *Config) { c.VmssCacheTTL = -1 },
+			expectedErr: "Config.VmssCacheTTL",
+		},
+		{
+			name:        "missing cloud name",
+			mutate:      func(c *Config) { c.Cloud = "" },
+			expectedErr: "Config.Cloud is a required field",
+		},
+		{
+			name:        "invalid cloud name",
+			mutate:      func(c *Config) { c.Cloud = "PrivateCloud" },
+			expectedErr: "Config.Cloud",
+		},
+		{
+			name:        "malformed tenantId",
+			mutate:      func(c *Config) { c.TenantID = "not-a-uuid" },
+			expectedErr: "Config.TenantID",
+		},
+		{
+			name:        "malformed subscriptionId",
+			mutate:      func(c *Config) { c.SubscriptionID = "not-a-uuid" },
+			expectedErr: "Config.SubscriptionID",
+		},
+		{
+			name: "interfaceRateLimit with standard vmType",
+			mutate: func(c *Config) {
+				c.VMType = vmTypeStandard
+				c.InterfaceRateLimit = &azclients.RateLimitConfig{}
+			},
+		},
+		{
+			name: "interfaceRateLimit with vmss vmType",
+			mutate: func(c *Config) {
+				c.VMType = vmTypeVMSS
+				c.InterfaceRateLimit = &azclients.RateLimitConfig{}
+			},
+			expectedErr: "interfaceRateLimit is only supported for vmType",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := validTestConfig()
+			tc.mutate(cfg)
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+func newTestAzureManager(t *testing.T) *AzureManager {
+	manager, err := CreateAzureManager(strings.NewReader(validAzureCfg), cloudprovider.NodeGroupDiscoveryOptions{})
+	assert.NoError(t, err)
+	return manager
+}
+
+func newTestVMSSList(count int64, name, location string) []compute.VirtualMachineScaleSet {
+	return []compute.VirtualMachineScaleSet{
+		{
+			Name:     &name,
+			Location: &location,
+			Sku: &compute.Sku{
+				Capacity: &count,
+			},
+		},
+	}
+}
+
+func newTestVMSSVMList() []compute.VirtualMachineScaleSetVM {
+	instanceID := "0"
+	return []compute.VirtualMachineScaleSetVM{
+		{
+			InstanceID: &instanceID,
+		},
+	}
+}
+
+func fakeVMSSWithTags(name string, tags map[string]*string) compute.VirtualMachineScaleSet {
+	return compute.VirtualMachineScaleSet{
+		Name: &name,
+		Tags: tags,
+	}
+}
@@ -0,0 +1,537 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+
+	azclients "k8s.io/legacy-cloud-providers/azure/clients"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+)
+
+const (
+	vmTypeVMSS     = "vmss"
+	vmTypeStandard = "standard"
+
+	rateLimitQPSDefault             float32 = 1.0
+	rateLimitBucketDefault          int     = 5
+	rateLimitReadQPSEnvVar                  = "RATE_LIMIT_READ_QPS"
+	rateLimitReadBucketsEnvVar              = "RATE_LIMIT_READ_BUCKETS"
+	rateLimitWriteQPSEnvVar                 = "RATE_LIMIT_WRITE_QPS"
+	rateLimitWriteBucketsEnvVar             = "RATE_LIMIT_WRITE_BUCKETS"
+)
+
+// Config holds the configuration parsed from the --cloud-config flag used to
+// set up the Azure manager.
+type Config struct {
+	Cloud    string `json:"cloud" yaml:"cloud" validate:"required,oneof=AzurePublicCloud AzureChinaCloud AzureGermanCloud AzureUSGovernmentCloud"`
+	Location string `json:"location" yaml:"location" validate:"required"`
+
+	TenantID       string `json:"tenantId" yaml:"tenantId" validate:"required,uuid"`
+	SubscriptionID string `json:"subscriptionId" yaml:"subscriptionId" validate:"required,uuid"`
+	ResourceGroup  string `json:"resourceGroup" yaml:"resourceGroup" validate:"required"`
+	VMType         string `json:"vmType" yaml:"vmType" validate:"required,oneof=vmss standard"`
+
+	AADClientID     string `json:"aadClientId" yaml:"aadClientId" validate:"required"`
+	AADClientSecret string `json:"aadClientSecret" yaml:"aadClientSecret" validate:"required"`
+
+	VmssCacheTTL        int64 `json:"vmssCacheTTL" yaml:"vmssCacheTTL" validate:"gte=0"`
+	MaxDeploymentsCount int64 `json:"maxDeploymentsCount" yaml:"maxDeploymentsCount" validate:"gt=0"`
+
+	CloudProviderRateLimitConfig `json:",inline" yaml:",inline"`
+}
+
+// CloudProviderRateLimitConfig indicates the rate limit config for each
+// clients.
+type CloudProviderRateLimitConfig struct {
+	azclients.RateLimitConfig `json:",inline" yaml:",inline"`
+
+	InterfaceRateLimit              *azclients.RateLimitConfig `json:"interfaceRateLimit,omitempty" yaml:"interfaceRateLimit,omitempty"`
+	VirtualMachineRateLimit         *azclients.RateLimitConfig `json:"virtualMachineRateLimit,omitempty" yaml:"virtualMachineRateLimit,omitempty"`
+	StorageAccountRateLimit         *azclients.RateLimitConfig `json:"storageAccountRateLimit,omitempty" yaml:"storageAccountRateLimit,omitempty"`
+	DiskRateLimit                   *azclients.RateLimitConfig `json:"diskRateLimit,omitempty" yaml:"diskRateLimit,omitempty"`
+	VirtualMachineScaleSetRateLimit *azclients.RateLimitConfig `json:"virtualMachineScaleSetRateLimit,omitempty" yaml:"virtualMachineScaleSetRateLimit,omitempty"`
+}
+
+// InitializeCloudProviderRateLimitConfig initializes rate limit configs.
+func InitializeCloudProviderRateLimitConfig(config *CloudProviderRateLimitConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.CloudProviderRateLimitQPS == 0 {
+		config.CloudProviderRateLimitQPS = rateLimitQPSDefault
+		if qps, err := parseFloat32EnvVar(rateLimitReadQPSEnvVar); err == nil {
+			config.CloudProviderRateLimitQPS = qps
+		}
+	}
+	if config.CloudProviderRateLimitBucket == 0 {
+		config.CloudProviderRateLimitBucket = rateLimitBucketDefault
+		if buckets, err := parseIntEnvVar(rateLimitReadBucketsEnvVar); err == nil {
+			config.CloudProviderRateLimitBucket = buckets
+		}
+	}
+	if config.CloudProviderRateLimitQPSWrite == 0 {
+		config.CloudProviderRateLimitQPSWrite = config.CloudProviderRateLimitQPS
+		if qps, err := parseFloat32EnvVar(rateLimitWriteQPSEnvVar); err == nil {
+			config.CloudProviderRateLimitQPSWrite = qps
+		}
+	}
+	if config.CloudProviderRateLimitBucketWrite == 0 {
+		config.CloudProviderRateLimitBucketWrite = config.CloudProviderRateLimitBucket
+		if buckets, err := parseIntEnvVar(rateLimitWriteBucketsEnvVar); err == nil {
+			config.CloudProviderRateLimitBucketWrite = buckets
+		}
+	}
+
+	defaultPerClientRateLimitConfig(&config.InterfaceRateLimit, config.RateLimitConfig)
+	defaultPerClientRateLimitConfig(&config.VirtualMachineRateLimit, config.RateLimitConfig)
+	defaultPerClientRateLimitConfig(&config.StorageAccountRateLimit, config.RateLimitConfig)
+	defaultPerClientRateLimitConfig(&config.DiskRateLimit, config.RateLimitConfig)
+	defaultPerClientRateLimitConfig(&config.VirtualMachineScaleSetRateLimit, config.RateLimitConfig)
+
+	return nil
+}
+
+// defaultPerClientRateLimitConfig clones defaults into *override if the
+// per-client override wasn't set, so every client-specific rate limiter
+// (interface, VM, storage account, disk, VMSS) falls back to the same
+// resolved top-level defaults instead of staying nil.
+func defaultPerClientRateLimitConfig(override **azclients.RateLimitConfig, defaults azclients.RateLimitConfig) {
+	if *override != nil {
+		return
+	}
+	clone := defaults
+	*override = &clone
+}
+
+func parseFloat32EnvVar(name string) (float32, error) {
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return 0, fmt.Errorf("%s not set", name)
+	}
+	parsed, err := strconv.ParseFloat(val, 32)
+	if err != nil {
+		return 0, err
+	}
+	return float32(parsed), nil
+}
+
+func parseIntEnvVar(name string) (int, error) {
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return 0, fmt.Errorf("%s not set", name)
+	}
+	return strconv.Atoi(val)
+}
+
+// AzureManager handles Azure communication and data caching of node groups
+// (ASGs).
+type AzureManager struct {
+	config   *Config
+	azClient *azClient
+	ctx      context.Context
+
+	// rateLimiter is the manager-wide limiter used by ScaleSets that have
+	// no per-VMSS rate-limit tag override.
+	rateLimiter flowcontrol.RateLimiter
+
+	asgCache              *asgCache
+	asgAutoDiscoverySpecs []labelAutoDiscoveryConfig
+}
+
+// CreateAzureManager creates Azure Manager object to work with Azure. Any
+// overrides are merged on top of the file and AZURE_* env config, last one
+// winning, completing the file -> env -> override precedence pipeline.
+func CreateAzureManager(configReader io.Reader, discoveryOpts cloudprovider.NodeGroupDiscoveryOptions, overrides ...*Config) (*AzureManager, error) {
+	cfg := &Config{}
+
+	if configReader != nil {
+		body, err := ioutil.ReadAll(configReader)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseConfig(body)
+		if err != nil {
+			return nil, err
+		}
+		cfg = parsed
+	}
+
+	return newAzureManager(cfg, discoveryOpts, overrides...)
+}
+
+// CreateAzureManagerFromFile is like CreateAzureManager but reads the config
+// from a path instead of an already-open reader, dispatching on the file
+// extension so operators can ship either a JSON or a YAML cloud-config
+// alongside their Kubernetes manifests.
+func CreateAzureManagerFromFile(path string, discoveryOpts cloudprovider.NodeGroupDiscoveryOptions, overrides ...*Config) (*AzureManager, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cloud config %q: %v", path, err)
+	}
+
+	cfg, err := parseConfigFile(path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAzureManager(cfg, discoveryOpts, overrides...)
+}
+
+// newAzureManager completes the file -> env -> override precedence pipeline
+// on top of an already-parsed cfg and assembles the AzureManager, shared by
+// CreateAzureManager and CreateAzureManagerFromFile regardless of how cfg
+// was sourced.
+func newAzureManager(cfg *Config, discoveryOpts cloudprovider.NodeGroupDiscoveryOptions, overrides ...*Config) (*AzureManager, error) {
+	applyConfigEnvOverrides(cfg)
+
+	for _, override := range overrides {
+		cfg = cfg.Merge(override)
+	}
+
+	if cfg.VMType == "" {
+		cfg.VMType = vmTypeVMSS
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := InitializeCloudProviderRateLimitConfig(&cfg.CloudProviderRateLimitConfig); err != nil {
+		return nil, err
+	}
+
+	azClient, err := newAzClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := &AzureManager{
+		config:      cfg,
+		azClient:    azClient,
+		ctx:         context.Background(),
+		asgCache:    newAsgCache(),
+		rateLimiter: buildRateLimiter(&cfg.CloudProviderRateLimitConfig.RateLimitConfig),
+	}
+
+	specs, err := parseLabelAutoDiscoverySpecs(discoveryOpts)
+	if err != nil {
+		return nil, err
+	}
+	manager.asgAutoDiscoverySpecs = specs
+
+	if err := manager.fetchExplicitAsgs(discoveryOpts.NodeGroupSpecs); err != nil {
+		return nil, err
+	}
+
+	return manager, nil
+}
+
+// fetchExplicitAsgs parses the --nodes flag specs and registers the
+// resulting ScaleSets in the cache.
+func (m *AzureManager) fetchExplicitAsgs(specs []string) error {
+	for _, spec := range specs {
+		asg, err := m.buildAsgFromSpec(spec)
+		if err != nil {
+			return fmt.Errorf("failed to parse node group spec: %v", err)
+		}
+		m.asgCache.register(asg)
+	}
+	return nil
+}
+
+func (m *AzureManager) buildAsgFromSpec(spec string) (cloudprovider.NodeGroup, error) {
+	tokens := strings.SplitN(spec, ":", 3)
+	if len(tokens) != 3 {
+		return nil, fmt.Errorf("invalid node group spec: %s", spec)
+	}
+
+	min, err := strconv.Atoi(tokens[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid minimum size specified for node group: %s", spec)
+	}
+	max, err := strconv.Atoi(tokens[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid maximum size specified for node group: %s", spec)
+	}
+
+	return NewScaleSet(tokens[2], min, max, m), nil
+}
+
+// listScaleSets lists all VMSS matching the given auto discovery specs,
+// scoped to whichever resource groups the specs select (or the manager's
+// configured resource group if none of them carry an rg: selector).
+func (m *AzureManager) listScaleSets(specs []labelAutoDiscoveryConfig) ([]cloudprovider.NodeGroup, error) {
+	var result []cloudprovider.NodeGroup
+
+	groups, err := m.resourceGroupsForSpecs(specs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rg := range groups {
+		vmssList, err := m.azClient.virtualMachineScaleSetsClient.List(m.ctx, rg)
+		if err != nil {
+			return nil, err
+		}
+
+		asgs, err := m.scaleSetsFromList(vmssList, specs)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, asgs...)
+	}
+
+	return result, nil
+}
+
+// resourceGroupsForSpecs returns the distinct resource groups that the given
+// specs' rg: selectors resolve to, falling back to the manager's configured
+// resource group when none of the specs scope discovery to a group.
+//
+// This manager holds no Resource Groups client, so it can't enumerate and
+// match rg:~ regex selectors against every group in the subscription -
+// instead it checks the regex against the manager's own configured resource
+// group and errors if that group doesn't match, rather than silently
+// searching a group the selector was never meant to include.
+func (m *AzureManager) resourceGroupsForSpecs(specs []labelAutoDiscoveryConfig) ([]string, error) {
+	seen := make(map[string]bool)
+	var groups []string
+
+	addGroup := func(name string) {
+		if name == "" || seen[name] {
+			return
+		}
+		seen[name] = true
+		groups = append(groups, name)
+	}
+
+	for _, spec := range specs {
+		for _, rg := range spec.ResourceGroups {
+			if rg.Regex != nil {
+				if !rg.Regex.MatchString(m.config.ResourceGroup) {
+					return nil, fmt.Errorf("resource group selector %q does not match the configured resource group %q, and no other resource groups are available to search", rg.Regex.String(), m.config.ResourceGroup)
+				}
+				addGroup(m.config.ResourceGroup)
+				continue
+			}
+			addGroup(rg.Name)
+		}
+	}
+
+	if len(groups) == 0 {
+		addGroup(m.config.ResourceGroup)
+	}
+	return groups, nil
+}
+
+func (m *AzureManager) scaleSetsFromList(vmssList []compute.VirtualMachineScaleSet, specs []labelAutoDiscoveryConfig) ([]cloudprovider.NodeGroup, error) {
+	var result []cloudprovider.NodeGroup
+	for _, scaleSet := range vmssList {
+		if scaleSet.Tags == nil {
+			continue
+		}
+
+		if !matchDiscoverySpecs(scaleSet.Tags, specs) {
+			continue
+		}
+
+		name := *scaleSet.Name
+
+		minValue, ok := scaleSet.Tags["min"]
+		if !ok || minValue == nil {
+			return nil, fmt.Errorf("no minimum size specified for vmss: %s", name)
+		}
+		min, err := strconv.Atoi(*minValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minimum size specified for vmss: %s: %v", name, err)
+		}
+
+		maxValue, ok := scaleSet.Tags["max"]
+		if !ok || maxValue == nil {
+			return nil, fmt.Errorf("no maximum size specified for vmss: %s", name)
+		}
+		max, err := strconv.Atoi(*maxValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maximum size specified for vmss: %s: %v", name, err)
+		}
+
+		if min < 0 {
+			return nil, fmt.Errorf("minimum size must be a non-negative number of nodes")
+		}
+		if max < min {
+			return nil, fmt.Errorf("maximum size must be greater than minimum size")
+		}
+
+		buffer, err := parseBufferPolicy(scaleSet.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("invalid buffer policy for vmss: %s: %v", name, err)
+		}
+
+		rateLimitConfig, err := parseScaleSetRateLimitOverride(scaleSet.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate-limit override for vmss: %s: %v", name, err)
+		}
+
+		asg := NewScaleSet(name, min, max, m)
+		asg.rateLimitConfig = rateLimitConfig
+		asg.bufferPolicy = buffer
+		result = append(result, asg)
+	}
+
+	return result, nil
+}
+
+// getFilteredAutoscalingGroups returns all VMSS matching the given auto
+// discovery specs.
+func (m *AzureManager) getFilteredAutoscalingGroups(specs []labelAutoDiscoveryConfig) ([]cloudprovider.NodeGroup, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	return m.listScaleSets(specs)
+}
+
+// fetchAutoAsgs re-runs auto discovery and registers any newly found ASGs
+// in the cache.
+func (m *AzureManager) fetchAutoAsgs() error {
+	groups, err := m.getFilteredAutoscalingGroups(m.asgAutoDiscoverySpecs)
+	if err != nil {
+		return fmt.Errorf("cannot autodiscover ASGs: %s", err)
+	}
+
+	for _, asg := range groups {
+		m.asgCache.register(asg)
+	}
+
+	m.applyBufferPolicies()
+	return nil
+}
+
+// applyBufferPolicies scales any ScaleSet that carries a buffer policy up to
+// its EffectiveTargetSize, so the autoscaler proactively keeps headroom
+// instead of only reacting once Pending pods appear. A single ScaleSet whose
+// utilization can't be read or whose IncreaseSize call fails is logged and
+// skipped rather than failing the whole refresh - a misconfigured or
+// unimplemented dependency on one VMSS shouldn't take discovery down for
+// every other node group.
+func (m *AzureManager) applyBufferPolicies() {
+	for _, group := range m.asgCache.get() {
+		ss, ok := group.(*ScaleSet)
+		if !ok || !ss.bufferPolicy.set {
+			continue
+		}
+
+		utilization, err := m.currentUtilization(ss, ss.bufferPolicy.key)
+		if err != nil {
+			klog.Errorf("buffer policy for vmss %s: %v", ss.Name, err)
+			continue
+		}
+
+		desired, err := ss.EffectiveTargetSize(utilization)
+		if err != nil {
+			klog.Errorf("buffer policy for vmss %s: %v", ss.Name, err)
+			continue
+		}
+
+		current, err := ss.TargetSize()
+		if err != nil {
+			klog.Errorf("buffer policy for vmss %s: %v", ss.Name, err)
+			continue
+		}
+		if desired <= current {
+			continue
+		}
+
+		if err := ss.IncreaseSize(desired - current); err != nil {
+			klog.Errorf("failed to apply buffer policy for vmss %s: %v", ss.Name, err)
+		}
+	}
+}
+
+// currentUtilization reads a ScaleSet's current utilization along the given
+// resource dimension (the bufferKey tag). This manager only holds the VMSS's
+// own instance list, not a cluster-wide node/pod cache, so "pods", "nodes"
+// and the default (empty) key are approximated as the scale set's current
+// instance count; any other key would need a metrics source this manager
+// doesn't have, so it errors instead of silently evaluating as zero
+// utilization.
+func (m *AzureManager) currentUtilization(scaleSet *ScaleSet, key string) (float64, error) {
+	switch key {
+	case "", "pods", "nodes":
+		instances, err := m.GetScaleSetVms(scaleSet)
+		if err != nil {
+			return 0, err
+		}
+		return float64(len(instances)), nil
+	default:
+		return 0, fmt.Errorf("bufferKey %q has no utilization source available", key)
+	}
+}
+
+// Refresh refreshes the cache of running scale sets.
+func (m *AzureManager) Refresh() error {
+	return m.fetchAutoAsgs()
+}
+
+// GetScaleSetVms returns list of nodes for the given scale set.
+func (m *AzureManager) GetScaleSetVms(scaleSet *ScaleSet) ([]cloudprovider.Instance, error) {
+	scaleSet.getRateLimiter().Accept()
+	vms, err := m.azClient.virtualMachineScaleSetVMsClient.List(m.ctx, m.config.ResourceGroup, scaleSet.Name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]cloudprovider.Instance, 0, len(vms))
+	for _, vm := range vms {
+		if vm.InstanceID == nil {
+			continue
+		}
+		instances = append(instances, cloudprovider.Instance{
+			Id: azureRefIDForInstance(scaleSet.Name, *vm.InstanceID),
+		})
+	}
+	return instances, nil
+}
+
+func azureRefIDForInstance(vmssName, instanceID string) string {
+	return fmt.Sprintf("azure://%s_%s", vmssName, instanceID)
+}
+
+// SetScaleSetSize is not implemented: it makes no Azure call and always
+// returns an error, so the scale set's size is left unchanged.
+func (m *AzureManager) SetScaleSetSize(scaleSet *ScaleSet, size int64) error {
+	return fmt.Errorf("not implemented")
+}
+
+// DeleteInstances is not implemented: it makes no Azure call and always
+// returns an error, so no nodes are removed from the scale set.
+func (m *AzureManager) DeleteInstances(scaleSet *ScaleSet, nodes []*apiv1.Node) error {
+	return fmt.Errorf("not implemented")
+}
@@ -35,8 +35,8 @@ import (
 
 const validAzureCfg = `{
 	"cloud": "AzurePublicCloud",
-	"tenantId": "fakeId",
-	"subscriptionId": "fakeId",
+	"tenantId": "11111111-1111-1111-1111-111111111111",
+	"subscriptionId": "22222222-2222-2222-2222-222222222222",
 	"aadClientId": "fakeId",
 	"aadClientSecret": "fakeId",
 	"resourceGroup": "fakeId",
@@ -63,8 +63,8 @@ func TestCreateAzureManagerValidConfig(t *testing.T) {
 	expectedConfig := &Config{
 		Cloud:               "AzurePublicCloud",
 		Location:            "southeastasia",
-		TenantID:            "fakeId",
-		SubscriptionID:      "fakeId",
+		TenantID:            "11111111-1111-1111-1111-111111111111",
+		SubscriptionID:      "22222222-2222-2222-2222-222222222222",
 		ResourceGroup:       "fakeId",
 		VMType:              "vmss",
 		AADClientID:         "fakeId",
@@ -447,6 +447,34 @@ func TestInitializeCloudProviderRateLimitConfigWithReadAndWriteRateLimitSettings
 	os.Unsetenv(rateLimitWriteBucketsEnvVar)
 }
 
+func TestInitializeCloudProviderRateLimitConfigDefaultsPerClientOverrides(t *testing.T) {
+	emptyConfig := &CloudProviderRateLimitConfig{}
+	err := InitializeCloudProviderRateLimitConfig(emptyConfig)
+	assert.NoError(t, err)
+
+	for _, override := range []*azclients.RateLimitConfig{
+		emptyConfig.InterfaceRateLimit,
+		emptyConfig.VirtualMachineRateLimit,
+		emptyConfig.StorageAccountRateLimit,
+		emptyConfig.DiskRateLimit,
+		emptyConfig.VirtualMachineScaleSetRateLimit,
+	} {
+		if assert.NotNil(t, override) {
+			assert.Equal(t, emptyConfig.RateLimitConfig, *override)
+		}
+	}
+}
+
+func TestInitializeCloudProviderRateLimitConfigPreservesExplicitPerClientOverride(t *testing.T) {
+	explicit := &azclients.RateLimitConfig{CloudProviderRateLimitQPS: 42}
+	configWithOverride := &CloudProviderRateLimitConfig{InterfaceRateLimit: explicit}
+
+	err := InitializeCloudProviderRateLimitConfig(configWithOverride)
+	assert.NoError(t, err)
+	assert.Same(t, explicit, configWithOverride.InterfaceRateLimit)
+	assert.NotNil(t, configWithOverride.VirtualMachineRateLimit)
+}
+
 func TestInitializeCloudProviderRateLimitConfigWithReadAndWriteRateLimitAlreadySetInConfig(t *testing.T) {
 	var rateLimitReadQPS float32 = 3.0
 	rateLimitReadBuckets := 10
@@ -0,0 +1,116 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"strconv"
+
+	"k8s.io/client-go/util/flowcontrol"
+	azclients "k8s.io/legacy-cloud-providers/azure/clients"
+)
+
+const (
+	vmssRateLimitQPSTag         = "cluster-autoscaler-rate-limit-qps"
+	vmssRateLimitBucketTag      = "cluster-autoscaler-rate-limit-bucket"
+	vmssRateLimitQPSWriteTag    = "cluster-autoscaler-rate-limit-qps-write"
+	vmssRateLimitBucketWriteTag = "cluster-autoscaler-rate-limit-bucket-write"
+)
+
+// parseScaleSetRateLimitOverride reads the cluster-autoscaler-rate-limit-*
+// tags off a VMSS and returns a RateLimitConfig built from whichever of
+// them are present, or nil if none are set so the ScaleSet falls back to
+// the manager's global limiter. A malformed tag is a hard error, matching
+// how scaleSetsFromList treats a malformed min/max tag.
+func parseScaleSetRateLimitOverride(tags map[string]*string) (*azclients.RateLimitConfig, error) {
+	qps, hasQPS, err := tagFloat32(tags, vmssRateLimitQPSTag)
+	if err != nil {
+		return nil, err
+	}
+	bucket, hasBucket, err := tagInt(tags, vmssRateLimitBucketTag)
+	if err != nil {
+		return nil, err
+	}
+	qpsWrite, hasQPSWrite, err := tagFloat32(tags, vmssRateLimitQPSWriteTag)
+	if err != nil {
+		return nil, err
+	}
+	bucketWrite, hasBucketWrite, err := tagInt(tags, vmssRateLimitBucketWriteTag)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hasQPS && !hasBucket && !hasQPSWrite && !hasBucketWrite {
+		return nil, nil
+	}
+
+	override := &azclients.RateLimitConfig{CloudProviderRateLimit: true}
+
+	override.CloudProviderRateLimitQPS = rateLimitQPSDefault
+	if hasQPS {
+		override.CloudProviderRateLimitQPS = qps
+	}
+	override.CloudProviderRateLimitBucket = rateLimitBucketDefault
+	if hasBucket {
+		override.CloudProviderRateLimitBucket = bucket
+	}
+
+	override.CloudProviderRateLimitQPSWrite = override.CloudProviderRateLimitQPS
+	if hasQPSWrite {
+		override.CloudProviderRateLimitQPSWrite = qpsWrite
+	}
+	override.CloudProviderRateLimitBucketWrite = override.CloudProviderRateLimitBucket
+	if hasBucketWrite {
+		override.CloudProviderRateLimitBucketWrite = bucketWrite
+	}
+
+	return override, nil
+}
+
+func tagFloat32(tags map[string]*string, key string) (float32, bool, error) {
+	v, ok := tags[key]
+	if !ok || v == nil {
+		return 0, false, nil
+	}
+	parsed, err := strconv.ParseFloat(*v, 32)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid value for tag %s: %s: %v", key, *v, err)
+	}
+	return float32(parsed), true, nil
+}
+
+func tagInt(tags map[string]*string, key string) (int, bool, error) {
+	v, ok := tags[key]
+	if !ok || v == nil {
+		return 0, false, nil
+	}
+	parsed, err := strconv.Atoi(*v)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid value for tag %s: %s: %v", key, *v, err)
+	}
+	return parsed, true, nil
+}
+
+// buildRateLimiter constructs a flowcontrol.RateLimiter from the given
+// config, falling back to an always-allow limiter when rate limiting is
+// disabled or no config was supplied.
+func buildRateLimiter(cfg *azclients.RateLimitConfig) flowcontrol.RateLimiter {
+	if cfg == nil || !cfg.CloudProviderRateLimit {
+		return flowcontrol.NewFakeAlwaysRateLimiter()
+	}
+	return flowcontrol.NewTokenBucketRateLimiter(cfg.CloudProviderRateLimitQPS, cfg.CloudProviderRateLimitBucket)
+}
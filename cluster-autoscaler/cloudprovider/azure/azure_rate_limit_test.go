@@ -0,0 +1,141 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/legacy-cloud-providers/azure/clients/vmssclient/mockvmssclient"
+)
+
+func TestListScalesetsRateLimitOverride(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	manager := newTestAzureManager(t)
+	vmssName := "rate-limited-vmss"
+	min, max := "1", "5"
+	qps, bucket := "2.5", "7"
+
+	tags := map[string]*string{
+		"fake-tag":             strPtr("fake-value"),
+		"min":                  &min,
+		"max":                  &max,
+		vmssRateLimitQPSTag:    &qps,
+		vmssRateLimitBucketTag: &bucket,
+	}
+	expectedScaleSets := []compute.VirtualMachineScaleSet{fakeVMSSWithTags(vmssName, tags)}
+
+	mockVMSSClient := mockvmssclient.NewMockInterface(ctrl)
+	mockVMSSClient.EXPECT().List(gomock.Any(), manager.config.ResourceGroup).Return(expectedScaleSets, nil).AnyTimes()
+	manager.azClient.virtualMachineScaleSetsClient = mockVMSSClient
+
+	specs := []labelAutoDiscoveryConfig{{Selector: map[string]string{"fake-tag": "fake-value"}}}
+	asgs, err := manager.listScaleSets(specs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(asgs))
+
+	ss := asgs[0].(*ScaleSet)
+	assert.NotNil(t, ss.rateLimitConfig)
+	assert.Equal(t, float32(2.5), ss.rateLimitConfig.CloudProviderRateLimitQPS)
+	assert.Equal(t, 7, ss.rateLimitConfig.CloudProviderRateLimitBucket)
+
+	assert.NotSame(t, manager.rateLimiter, ss.getRateLimiter())
+}
+
+func TestListScalesetsNoRateLimitOverrideReusesGlobalLimiter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	manager := newTestAzureManager(t)
+	vmssName := "plain-vmss"
+	min, max := "1", "5"
+
+	tags := map[string]*string{
+		"fake-tag": strPtr("fake-value"),
+		"min":      &min,
+		"max":      &max,
+	}
+	expectedScaleSets := []compute.VirtualMachineScaleSet{fakeVMSSWithTags(vmssName, tags)}
+
+	mockVMSSClient := mockvmssclient.NewMockInterface(ctrl)
+	mockVMSSClient.EXPECT().List(gomock.Any(), manager.config.ResourceGroup).Return(expectedScaleSets, nil).AnyTimes()
+	manager.azClient.virtualMachineScaleSetsClient = mockVMSSClient
+
+	specs := []labelAutoDiscoveryConfig{{Selector: map[string]string{"fake-tag": "fake-value"}}}
+	asgs, err := manager.listScaleSets(specs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(asgs))
+
+	ss := asgs[0].(*ScaleSet)
+	assert.Nil(t, ss.rateLimitConfig)
+	assert.Same(t, manager.rateLimiter, ss.getRateLimiter())
+}
+
+func TestParseScaleSetRateLimitOverride(t *testing.T) {
+	override, err := parseScaleSetRateLimitOverride(map[string]*string{})
+	assert.NoError(t, err)
+	assert.Nil(t, override)
+
+	qps := "4"
+	override, err = parseScaleSetRateLimitOverride(map[string]*string{vmssRateLimitQPSTag: &qps})
+	assert.NoError(t, err)
+	assert.NotNil(t, override)
+	assert.Equal(t, float32(4), override.CloudProviderRateLimitQPS)
+	assert.Equal(t, float32(4), override.CloudProviderRateLimitQPSWrite)
+	assert.Equal(t, rateLimitBucketDefault, override.CloudProviderRateLimitBucket)
+}
+
+func TestParseScaleSetRateLimitOverrideMalformedTag(t *testing.T) {
+	qps := "not-a-number"
+	_, err := parseScaleSetRateLimitOverride(map[string]*string{vmssRateLimitQPSTag: &qps})
+	assert.Error(t, err)
+
+	bucket := "not-a-number"
+	_, err = parseScaleSetRateLimitOverride(map[string]*string{vmssRateLimitBucketTag: &bucket})
+	assert.Error(t, err)
+}
+
+func TestListScalesetsMalformedRateLimitTagFails(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	manager := newTestAzureManager(t)
+	vmssName := "malformed-rate-limit-vmss"
+	min, max := "1", "5"
+	qps := "not-a-number"
+
+	tags := map[string]*string{
+		"fake-tag":          strPtr("fake-value"),
+		"min":               &min,
+		"max":               &max,
+		vmssRateLimitQPSTag: &qps,
+	}
+	expectedScaleSets := []compute.VirtualMachineScaleSet{fakeVMSSWithTags(vmssName, tags)}
+
+	mockVMSSClient := mockvmssclient.NewMockInterface(ctrl)
+	mockVMSSClient.EXPECT().List(gomock.Any(), manager.config.ResourceGroup).Return(expectedScaleSets, nil).AnyTimes()
+	manager.azClient.virtualMachineScaleSetsClient = mockVMSSClient
+
+	specs := []labelAutoDiscoveryConfig{{Selector: map[string]string{"fake-tag": "fake-value"}}}
+	_, err := manager.listScaleSets(specs)
+	assert.Error(t, err)
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2019 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azure
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/services/compute/mgmt/2019-12-01/compute"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/flowcontrol"
+	schedulerframework "k8s.io/kubernetes/pkg/scheduler/framework"
+	azclients "k8s.io/legacy-cloud-providers/azure/clients"
+
+	"k8s.io/autoscaler/cluster-autoscaler/cloudprovider"
+	"k8s.io/autoscaler/cluster-autoscaler/config"
+)
+
+// defaultVmssSizeRefreshPeriod is how long a ScaleSet's cached curSize is
+// trusted before the next call re-queries the VMSS.
+const defaultVmssSizeRefreshPeriod = 30 * time.Second
+
+// azureRef identifies an Azure resource by name, mirroring how the SDK
+// addresses VMSS instances and scale sets alike.
+type azureRef struct {
+	Name string
+}
+
+// ScaleSet implements cloudprovider.NodeGroup on top of an Azure VMSS.
+type ScaleSet struct {
+	azureRef
+	manager *AzureManager
+
+	minSize int
+	maxSize int
+
+	// rateLimitConfig is a per-VMSS rate-limit override read from the
+	// scale-set's cluster-autoscaler-rate-limit-* tags during discovery. Nil
+	// means this ScaleSet shares the manager's global limiter.
+	rateLimitConfig *azclients.RateLimitConfig
+	rateLimiterOnce sync.Once
+	rateLimiter     flowcontrol.RateLimiter
+
+	// bufferPolicy is this VMSS's capacity-buffer scaling policy, parsed
+	// from its buffer/bufferKey tags during discovery. The zero value means
+	// no buffer policy: EffectiveTargetSize falls back to TargetSize.
+	bufferPolicy bufferPolicy
+
+	sizeMutex         sync.Mutex
+	curSize           int64
+	lastSizeRefresh   time.Time
+	sizeRefreshPeriod time.Duration
+}
+
+// NewScaleSet creates a ScaleSet for the given VMSS name and min/max bounds.
+func NewScaleSet(name string, minSize, maxSize int, manager *AzureManager) *ScaleSet {
+	return &ScaleSet{
+		azureRef:          azureRef{Name: name},
+		manager:           manager,
+		minSize:           minSize,
+		maxSize:           maxSize,
+		curSize:           -1,
+		sizeRefreshPeriod: defaultVmssSizeRefreshPeriod,
+	}
+}
+
+// MaxSize returns the maximum size the VMSS is allowed to scale to.
+func (scaleSet *ScaleSet) MaxSize() int {
+	return scaleSet.maxSize
+}
+
+// MinSize returns the minimum size the VMSS is allowed to scale to.
+func (scaleSet *ScaleSet) MinSize() int {
+	return scaleSet.minSize
+}
+
+// TargetSize returns the current target size of the VMSS.
+func (scaleSet *ScaleSet) TargetSize() (int, error) {
+	size, err := scaleSet.getCurSize()
+	if err != nil {
+		return -1, err
+	}
+	return int(size), nil
+}
+
+func (scaleSet *ScaleSet) getCurSize() (int64, error) {
+	scaleSet.sizeMutex.Lock()
+	defer scaleSet.sizeMutex.Unlock()
+
+	if scaleSet.lastSizeRefresh.Add(scaleSet.sizeRefreshPeriod).After(time.Now()) && scaleSet.curSize != -1 {
+		return scaleSet.curSize, nil
+	}
+
+	set, err := scaleSet.getVMSS()
+	if err != nil {
+		return -1, err
+	}
+	if set.Sku != nil && set.Sku.Capacity != nil {
+		scaleSet.curSize = *set.Sku.Capacity
+		scaleSet.lastSizeRefresh = time.Now()
+	}
+	return scaleSet.curSize, nil
+}
+
+func (scaleSet *ScaleSet) getVMSS() (compute.VirtualMachineScaleSet, error) {
+	scaleSet.getRateLimiter().Accept()
+	return scaleSet.manager.azClient.virtualMachineScaleSetsClient.Get(scaleSet.manager.ctx, scaleSet.manager.config.ResourceGroup, scaleSet.Name)
+}
+
+// getRateLimiter returns the limiter that should gate calls for this
+// ScaleSet: its own override if the VMSS carries rate-limit tags, or the
+// manager's shared limiter otherwise. The result is cached on first use so
+// repeated calls reuse the same limiter instance (and its token bucket)
+// rather than resetting it on every API call.
+func (scaleSet *ScaleSet) getRateLimiter() flowcontrol.RateLimiter {
+	scaleSet.rateLimiterOnce.Do(func() {
+		if scaleSet.rateLimitConfig == nil {
+			scaleSet.rateLimiter = scaleSet.manager.rateLimiter
+			return
+		}
+		scaleSet.rateLimiter = buildRateLimiter(scaleSet.rateLimitConfig)
+	})
+	return scaleSet.rateLimiter
+}
+
+// EffectiveTargetSize returns the size this ScaleSet's buffer policy says it
+// should run at, given currentUtilization along the policy's bufferKey
+// dimension (as read by AzureManager.currentUtilization), clamped
+// to [MinSize, MaxSize]. ScaleSets with no buffer policy just return their
+// current TargetSize unchanged.
+func (scaleSet *ScaleSet) EffectiveTargetSize(currentUtilization float64) (int, error) {
+	if !scaleSet.bufferPolicy.set {
+		return scaleSet.TargetSize()
+	}
+	return scaleSet.bufferPolicy.effectiveSize(currentUtilization, scaleSet.minSize, scaleSet.maxSize), nil
+}
+
+// IncreaseSize requests that the VMSS grow by delta instances.
+func (scaleSet *ScaleSet) IncreaseSize(delta int) error {
+	if delta <= 0 {
+		return fmt.Errorf("size increase must be positive, got: %d", delta)
+	}
+
+	size, err := scaleSet.TargetSize()
+	if err != nil {
+		return err
+	}
+
+	if size+delta > scaleSet.MaxSize() {
+		return fmt.Errorf("size increase too large, desired: %d, max: %d", size+delta, scaleSet.MaxSize())
+	}
+
+	return scaleSet.manager.SetScaleSetSize(scaleSet, int64(size+delta))
+}
+
+// DecreaseTargetSize requests that the VMSS shrink by delta instances.
+func (scaleSet *ScaleSet) DecreaseTargetSize(delta int) error {
+	if delta >= 0 {
+		return fmt.Errorf("size decrease must be negative, got: %d", delta)
+	}
+
+	size, err := scaleSet.TargetSize()
+	if err != nil {
+		return err
+	}
+
+	if size+delta < scaleSet.MinSize() {
+		return fmt.Errorf("size decrease too large, desired: %d, min: %d", size+delta, scaleSet.MinSize())
+	}
+
+	return scaleSet.manager.SetScaleSetSize(scaleSet, int64(size+delta))
+}
+
+// DeleteNodes deletes the given nodes from the VMSS.
+func (scaleSet *ScaleSet) DeleteNodes(nodes []*apiv1.Node) error {
+	size, err := scaleSet.TargetSize()
+	if err != nil {
+		return err
+	}
+	if size <= scaleSet.MinSize() {
+		return fmt.Errorf("min size reached, nodes will not be deleted")
+	}
+	return scaleSet.manager.DeleteInstances(scaleSet, nodes)
+}
+
+// Id returns the VMSS name.
+func (scaleSet *ScaleSet) Id() string {
+	return scaleSet.Name
+}
+
+// Debug returns a human-readable description of the ScaleSet.
+func (scaleSet *ScaleSet) Debug() string {
+	return fmt.Sprintf("%s (%d:%d)", scaleSet.Id(), scaleSet.MinSize(), scaleSet.MaxSize())
+}
+
+// Nodes returns the instances currently belonging to the VMSS.
+func (scaleSet *ScaleSet) Nodes() ([]cloudprovider.Instance, error) {
+	return scaleSet.manager.GetScaleSetVms(scaleSet)
+}
+
+// TemplateNodeInfo is not implemented and always returns
+// cloudprovider.ErrNotImplemented.
+func (scaleSet *ScaleSet) TemplateNodeInfo() (*schedulerframework.NodeInfo, error) {
+	return nil, cloudprovider.ErrNotImplemented
+}
+
+// Exist always returns true; it does not query Azure for the VMSS.
+func (scaleSet *ScaleSet) Exist() bool {
+	return true
+}
+
+// Create is not supported; VMSS node groups must already exist.
+func (scaleSet *ScaleSet) Create() (cloudprovider.NodeGroup, error) {
+	return nil, cloudprovider.ErrAlreadyExist
+}
+
+// Delete is not supported; VMSS node groups must be removed out of band.
+func (scaleSet *ScaleSet) Delete() error {
+	return cloudprovider.ErrNotImplemented
+}
+
+// Autoprovisioned returns false; ScaleSets are always pre-provisioned by the
+// operator (via explicit specs or auto-discovery tags).
+func (scaleSet *ScaleSet) Autoprovisioned() bool {
+	return false
+}
+
+// GetOptions returns nil to fall back on the autoscaler's global options.
+func (scaleSet *ScaleSet) GetOptions(defaults config.NodeGroupAutoscalingOptions) (*config.NodeGroupAutoscalingOptions, error) {
+	return nil, cloudprovider.ErrNotImplemented
+}